@@ -0,0 +1,178 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	anthropicEndpoint = "https://api.anthropic.com/v1/messages"
+	anthropicModel    = "claude-3-5-haiku-latest"
+	anthropicVersion  = "2023-06-01"
+	anthropicTimeout  = 10 * time.Minute
+)
+
+type anthropicMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicRequestBody struct {
+	Model       string             `json:"model"`
+	System      string             `json:"system"`
+	Messages    []anthropicMessage `json:"messages"`
+	MaxTokens   int                `json:"max_tokens"`
+	Temperature float32            `json:"temperature"`
+	TopP        float32            `json:"top_p"`
+	TopK        int                `json:"top_k"`
+	Stream      bool               `json:"stream"`
+}
+
+type anthropicContentBlock struct {
+	Text string `json:"text"`
+}
+
+type anthropicResponseBody struct {
+	Content []anthropicContentBlock `json:"content"`
+}
+
+// anthropicStreamEvent covers the "content_block_delta" and "message_stop"
+// events of Anthropic's SSE stream; other event types are ignored.
+type anthropicStreamEvent struct {
+	Type  string `json:"type"`
+	Delta struct {
+		Text string `json:"text"`
+	} `json:"delta"`
+}
+
+// anthropicTransport talks to Anthropic's Messages API.
+type anthropicTransport struct {
+	endpoint string
+	model    string
+	apiKey   string
+}
+
+func newAnthropicTransport(cfg BackendConfig) Transport {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = anthropicEndpoint
+	}
+	model := cfg.Model
+	if model == "" {
+		model = anthropicModel
+	}
+	return &anthropicTransport{endpoint: endpoint, model: model, apiKey: apiKeyFromEnv(cfg)}
+}
+
+func anthropicMessages(prompt Prompt) []anthropicMessage {
+	messages := make([]anthropicMessage, 0, len(prompt.Dialog))
+	for _, line := range prompt.Dialog {
+		messages = append(messages, anthropicMessage{Role: "user", Content: line})
+	}
+	return messages
+}
+
+func (t *anthropicTransport) newRequest(ctx context.Context, prompt Prompt, params Params, stream bool) (*http.Request, error) {
+	body, err := json.Marshal(anthropicRequestBody{
+		Model:       t.model,
+		System:      prompt.System,
+		Messages:    anthropicMessages(prompt),
+		MaxTokens:   params.MaxTokens,
+		Temperature: params.Temperature,
+		TopP:        params.TopP,
+		TopK:        params.TopK,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", t.apiKey)
+	req.Header.Set("anthropic-version", anthropicVersion)
+	return req, nil
+}
+
+func (t *anthropicTransport) Generate(ctx context.Context, prompt Prompt, params Params) (string, error) {
+	req, err := t.newRequest(ctx, prompt, params, false)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: anthropicTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("[API] Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+
+	var responseBody anthropicResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&responseBody); err != nil {
+		return "", fmt.Errorf("[API] Failed to decode response: %v", err)
+	}
+	if len(responseBody.Content) == 0 {
+		return "", fmt.Errorf("[API] No content in response")
+	}
+
+	return responseBody.Content[0].Text, nil
+}
+
+func (t *anthropicTransport) Stream(ctx context.Context, prompt Prompt, params Params) (<-chan Chunk, error) {
+	req, err := t.newRequest(ctx, prompt, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: anthropicTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[API] Failed to open stream: %v", err)
+	}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(chunks)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+
+			var event anthropicStreamEvent
+			if err := json.Unmarshal([]byte(data), &event); err != nil {
+				continue
+			}
+
+			switch event.Type {
+			case "content_block_delta":
+				chunks <- Chunk{Text: event.Delta.Text}
+			case "message_stop":
+				chunks <- Chunk{Done: true}
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
@@ -1,12 +1,12 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"io"
 	"os"
 	"strings"
 	"time"
@@ -14,10 +14,8 @@ import (
 
 // Server specific constant
 const (
-	API          = "http://llm-server:8000/v1/generate"
 	MAX_SEND_TRY = 3
 	RETRY_TIME   = 5 * time.Second
-	API_TIMEOUT  = 10 * time.Minute
 )
 
 // Settings JSON representation
@@ -39,15 +37,67 @@ type Settings struct {
 	RateBatchSize int `json:"rate_batch_size"`
 }
 
-// Request to send
-type RequestBody struct {
-	Dialog   []string `json:"dialog"`
-	Settings Settings `json:"settings"`
+// Chunk is one piece of a streamed response. Tokens, when a backend
+// reports it, is the actual token count of the full response and arrives
+// on the final (Done) chunk.
+type Chunk struct {
+	Text   string `json:"text"`
+	Done   bool   `json:"done"`
+	Tokens int    `json:"tokens,omitempty"`
+}
+
+// Prompt is the fully-resolved conversation handed to a Transport.
+type Prompt struct {
+	System string
+	Dialog []string
+}
+
+// Params carries the generation knobs every Transport maps onto its own
+// request shape.
+type Params struct {
+	Temperature       float32
+	TopP              float32
+	TopK              int
+	RepetitionPenalty float32
+	MaxTokens         int
 }
 
-// Response to receive
-type ResponseBody struct {
-	Response string `json:"response"`
+// BackendConfig selects and configures a Transport for one bot
+// (init.json "backends").
+type BackendConfig struct {
+	Kind     string `json:"kind"`     // "custom" (default), "openai", "anthropic", "ollama"
+	Endpoint string `json:"endpoint"` // overrides the transport's default endpoint
+	Model    string `json:"model"`    // provider model name, ignored by "custom"
+	KeyEnv   string `json:"key_env"`  // env var holding the provider API key
+}
+
+// Transport generates, or streams, a completion from one LLM backend.
+type Transport interface {
+	Generate(ctx context.Context, prompt Prompt, params Params) (string, error)
+	Stream(ctx context.Context, prompt Prompt, params Params) (<-chan Chunk, error)
+}
+
+// Named Transport constructors, selected by BackendConfig.Kind.
+var transports = map[string]func(BackendConfig) Transport{
+	"custom":    newCustomTransport,
+	"openai":    newOpenAITransport,
+	"anthropic": newAnthropicTransport,
+	"ollama":    newOllamaTransport,
+}
+
+// NewTransport builds the Transport selected by cfg, falling back to the
+// original bespoke LLM server when no kind is given.
+func NewTransport(cfg BackendConfig) (Transport, error) {
+	kind := cfg.Kind
+	if kind == "" {
+		kind = "custom"
+	}
+
+	newTransport, ok := transports[kind]
+	if !ok {
+		return nil, fmt.Errorf("[OS] Unknown backend kind: %s", kind)
+	}
+	return newTransport(cfg), nil
 }
 
 func loadSettings(config string) Settings {
@@ -67,80 +117,116 @@ func loadSettings(config string) Settings {
 	return settings
 }
 
-// Request constructor
-func newRequestBody(dialog []string, config string) *RequestBody {
-	// Return request body: dialog, settings
-	return &RequestBody{
-		Dialog:   dialog,
-		Settings: loadSettings(config),
+// checkStatus turns any response other than 200 OK into an error carrying its body,
+// instead of letting the caller decode an error payload as if it were a
+// successful one.
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode == http.StatusOK {
+		return nil
 	}
+	body, _ := io.ReadAll(resp.Body)
+	return fmt.Errorf("[API] %s: %s", resp.Status, body)
 }
 
-func sendRequestBody(requestBody *RequestBody) (string, error) {
-	// Encode request body to JSON data
-	jsonData, err := json.Marshal(requestBody)
-	if err != nil {
-		return "", err
+func newParams(settings Settings) Params {
+	return Params{
+		Temperature:       settings.Temperature,
+		TopP:              settings.TopP,
+		TopK:              settings.TopK,
+		RepetitionPenalty: settings.RepetitionPenalty,
+		MaxTokens:         settings.RespTokens,
 	}
+}
 
-	// Make new POST request with JSON data
-	request, err := http.NewRequest("POST", API, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return "", fmt.Errorf("[API] Failed to make request: %s", err)
+// renderSystemPrompt fills the system prompt's single "%s" with chatTitle.
+func renderSystemPrompt(systemPrompt, chatTitle string) (string, error) {
+	if strings.Count(systemPrompt, "%s") != 1 {
+		errMsg := "[OS] No/many %%s in system prompt. Use one for chat title."
+		return "", fmt.Errorf(errMsg)
 	}
-	request.Header.Set("Content-Type", "application/json")
+	return fmt.Sprintf(systemPrompt, chatTitle), nil
+}
 
-	// Set HTTP client
-	client := &http.Client{Timeout: API_TIMEOUT}
-	resp, err := client.Do(request)
+func newPrompt(config, chatTitle string, dialog []string) (Prompt, Params, error) {
+	settings := loadSettings(config)
+
+	system, err := renderSystemPrompt(settings.SystemPrompt, chatTitle)
 	if err != nil {
-		return "", fmt.Errorf("[API] Failed to send request: %s", err)
+		return Prompt{}, Params{}, err
 	}
-	defer resp.Body.Close()
 
-	// Check status; print status code of error if any
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("Status %d: %s", resp.StatusCode, string(body))
+	return Prompt{System: system, Dialog: dialog}, newParams(settings), nil
+}
+
+// Send resolves backend's transport and delegates, retrying with backoff
+// whenever Generate fails (<MAX_SEND_TRY> tries).
+func Send(dialog []string, config string, chatTitle string, backend BackendConfig) (string, error) {
+	transport, err := NewTransport(backend)
+	if err != nil {
+		return "", err
 	}
 
-	// Decode response body
-	var responseBody ResponseBody
-	err = json.NewDecoder(resp.Body).Decode(&responseBody)
+	prompt, params, err := newPrompt(config, chatTitle, dialog)
 	if err != nil {
 		return "", err
 	}
 
-	return responseBody.Response, nil
+	// Send request (<MAX_SEND_TRY> tries)
+	var text string
+	for i := range MAX_SEND_TRY {
+		text, err = transport.Generate(context.Background(), prompt, params)
+		if err == nil {
+			break
+		}
+		log.Printf("[API] Try %d: %v", i, err)
+		time.Sleep(RETRY_TIME * (1 << (i + 1)))
+	}
+
+	return text, err
 }
 
-// Send request to LLM server
-func Send(dialog []string, config string, chatTitle string) (string, error) {
-	// Prepare request body
-	requestBody := newRequestBody(dialog, config)
+// SendStream resolves backend's transport and streams the response back
+// chunk by chunk. The retry-with-backoff behavior only covers opening the
+// connection (<MAX_SEND_TRY> tries); once the first chunk arrives, decode
+// errors simply end the stream. A Done chunk's Tokens is always set,
+// falling back to the configured response-token budget when the transport
+// itself didn't report actual usage; a stream that ends without a Done
+// chunk carries no token count.
+func SendStream(dialog []string, config string, chatTitle string, backend BackendConfig) (<-chan Chunk, error) {
+	transport, err := NewTransport(backend)
+	if err != nil {
+		return nil, err
+	}
 
-	// Add chat title to system prompt if space reserved
-	prompt := requestBody.Settings.SystemPrompt
-	if strings.Count(prompt, "%s") != 1 {  
-		errMsg := "[OS] No/many %%s in system prompt. Use one for chat title."
-		return "", fmt.Errorf(errMsg)  
-	}  
-	if strings.Contains(prompt, "%s") {
-		prompt = fmt.Sprintf(prompt, chatTitle)
+	prompt, params, err := newPrompt(config, chatTitle, dialog)
+	if err != nil {
+		return nil, err
 	}
-	requestBody.Settings.SystemPrompt = prompt
 
-	// Send request body (<MAX_SEND_TRY> tries)
-	var text string
-	var err error
+	// Open stream (<MAX_SEND_TRY> tries)
+	var raw <-chan Chunk
 	for i := range MAX_SEND_TRY {
-		text, err = sendRequestBody(requestBody)
+		raw, err = transport.Stream(context.Background(), prompt, params)
 		if err == nil {
 			break
 		}
 		log.Printf("[API] Try %d: %v", i, err)
-		time.Sleep(RETRY_TIME * (1 << (i+1)))
+		time.Sleep(RETRY_TIME * (1 << (i + 1)))
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return text, err
+	chunks := make(chan Chunk)
+	go func() {
+		defer close(chunks)
+		for chunk := range raw {
+			if chunk.Done && chunk.Tokens == 0 {
+				chunk.Tokens = params.MaxTokens
+			}
+			chunks <- chunk
+		}
+	}()
+
+	return chunks, nil
 }
@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Endpoints of the original bespoke LLM server.
+const (
+	customAPI       = "http://llm-server:8000/v1/generate"
+	customStreamAPI = "http://llm-server:8000/v1/generate/stream"
+	customTimeout   = 10 * time.Minute
+)
+
+// customRequestBody is the bespoke server's request shape: the whole
+// dialog plus every generation knob in one flat object.
+type customRequestBody struct {
+	Dialog       []string `json:"dialog"`
+	SystemPrompt string   `json:"system_prompt"`
+
+	Temperature       float32 `json:"temperature"`
+	RepetitionPenalty float32 `json:"repetition_penalty"`
+	TopP              float32 `json:"top_p"`
+	TopK              int     `json:"top_k"`
+	MaxTokens         int     `json:"max_tokens"`
+}
+
+type customResponseBody struct {
+	Response string `json:"response"`
+}
+
+// customTransport talks to the original bespoke LLM server.
+type customTransport struct {
+	endpoint       string
+	streamEndpoint string
+}
+
+func newCustomTransport(cfg BackendConfig) Transport {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = customAPI
+	}
+	return &customTransport{endpoint: endpoint, streamEndpoint: customStreamAPI}
+}
+
+func newCustomRequestBody(prompt Prompt, params Params) *customRequestBody {
+	return &customRequestBody{
+		Dialog:            prompt.Dialog,
+		SystemPrompt:      prompt.System,
+		Temperature:       params.Temperature,
+		RepetitionPenalty: params.RepetitionPenalty,
+		TopP:              params.TopP,
+		TopK:              params.TopK,
+		MaxTokens:         params.MaxTokens,
+	}
+}
+
+func (t *customTransport) Generate(ctx context.Context, prompt Prompt, params Params) (string, error) {
+	body, err := json.Marshal(newCustomRequestBody(prompt, params))
+	if err != nil {
+		return "", fmt.Errorf("[OS error] Failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("[OS error] Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: customTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("[API] Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+
+	var responseBody customResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&responseBody); err != nil {
+		return "", fmt.Errorf("[API] Failed to decode response: %v", err)
+	}
+
+	return responseBody.Response, nil
+}
+
+func (t *customTransport) Stream(ctx context.Context, prompt Prompt, params Params) (<-chan Chunk, error) {
+	body, err := json.Marshal(newCustomRequestBody(prompt, params))
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.streamEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: customTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[API] Failed to open stream: %v", err)
+	}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(chunks)
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var chunk Chunk
+			if err := decoder.Decode(&chunk); err != nil {
+				return
+			}
+			chunks <- chunk
+			if chunk.Done {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	ollamaEndpoint = "http://localhost:11434/api/chat"
+	ollamaModel    = "llama3"
+	ollamaTimeout  = 10 * time.Minute
+)
+
+type ollamaMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaOptions struct {
+	Temperature   float32 `json:"temperature"`
+	TopP          float32 `json:"top_p"`
+	TopK          int     `json:"top_k"`
+	RepeatPenalty float32 `json:"repeat_penalty"`
+	NumPredict    int     `json:"num_predict"`
+}
+
+type ollamaRequestBody struct {
+	Model    string          `json:"model"`
+	Messages []ollamaMessage `json:"messages"`
+	Options  ollamaOptions   `json:"options"`
+	Stream   bool            `json:"stream"`
+}
+
+type ollamaResponseBody struct {
+	Message ollamaMessage `json:"message"`
+	Done    bool          `json:"done"`
+}
+
+// ollamaTransport talks to a local Ollama server's /api/chat endpoint.
+type ollamaTransport struct {
+	endpoint string
+	model    string
+}
+
+func newOllamaTransport(cfg BackendConfig) Transport {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = ollamaEndpoint
+	}
+	model := cfg.Model
+	if model == "" {
+		model = ollamaModel
+	}
+	return &ollamaTransport{endpoint: endpoint, model: model}
+}
+
+func ollamaMessages(prompt Prompt) []ollamaMessage {
+	messages := []ollamaMessage{{Role: "system", Content: prompt.System}}
+	for _, line := range prompt.Dialog {
+		messages = append(messages, ollamaMessage{Role: "user", Content: line})
+	}
+	return messages
+}
+
+func (t *ollamaTransport) newRequest(ctx context.Context, prompt Prompt, params Params, stream bool) (*http.Request, error) {
+	body, err := json.Marshal(ollamaRequestBody{
+		Model:    t.model,
+		Messages: ollamaMessages(prompt),
+		Options: ollamaOptions{
+			Temperature:   params.Temperature,
+			TopP:          params.TopP,
+			TopK:          params.TopK,
+			RepeatPenalty: params.RepetitionPenalty,
+			NumPredict:    params.MaxTokens,
+		},
+		Stream: stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+func (t *ollamaTransport) Generate(ctx context.Context, prompt Prompt, params Params) (string, error) {
+	req, err := t.newRequest(ctx, prompt, params, false)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: ollamaTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("[API] Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+
+	var responseBody ollamaResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&responseBody); err != nil {
+		return "", fmt.Errorf("[API] Failed to decode response: %v", err)
+	}
+
+	return responseBody.Message.Content, nil
+}
+
+func (t *ollamaTransport) Stream(ctx context.Context, prompt Prompt, params Params) (<-chan Chunk, error) {
+	req, err := t.newRequest(ctx, prompt, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: ollamaTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[API] Failed to open stream: %v", err)
+	}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(chunks)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			var responseBody ollamaResponseBody
+			if err := json.Unmarshal(scanner.Bytes(), &responseBody); err != nil {
+				return
+			}
+			chunks <- Chunk{Text: responseBody.Message.Content, Done: responseBody.Done}
+			if responseBody.Done {
+				return
+			}
+		}
+	}()
+
+	return chunks, nil
+}
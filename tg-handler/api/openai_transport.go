@@ -0,0 +1,173 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	openAIEndpoint = "https://api.openai.com/v1/chat/completions"
+	openAIModel    = "gpt-4o-mini"
+	openAITimeout  = 10 * time.Minute
+)
+
+type openAIMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type openAIRequestBody struct {
+	Model       string          `json:"model"`
+	Messages    []openAIMessage `json:"messages"`
+	Temperature float32         `json:"temperature"`
+	TopP        float32         `json:"top_p"`
+	MaxTokens   int             `json:"max_tokens"`
+	Stream      bool            `json:"stream"`
+}
+
+type openAIChoice struct {
+	Message openAIMessage `json:"message"`
+	Delta   openAIMessage `json:"delta"`
+}
+
+type openAIResponseBody struct {
+	Choices []openAIChoice `json:"choices"`
+}
+
+// openAITransport talks to OpenAI's Chat Completions API.
+type openAITransport struct {
+	endpoint string
+	model    string
+	apiKey   string
+}
+
+func newOpenAITransport(cfg BackendConfig) Transport {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = openAIEndpoint
+	}
+	model := cfg.Model
+	if model == "" {
+		model = openAIModel
+	}
+	return &openAITransport{endpoint: endpoint, model: model, apiKey: apiKeyFromEnv(cfg)}
+}
+
+func openAIMessages(prompt Prompt) []openAIMessage {
+	messages := []openAIMessage{{Role: "system", Content: prompt.System}}
+	for _, line := range prompt.Dialog {
+		messages = append(messages, openAIMessage{Role: "user", Content: line})
+	}
+	return messages
+}
+
+func (t *openAITransport) newRequest(ctx context.Context, prompt Prompt, params Params, stream bool) (*http.Request, error) {
+	body, err := json.Marshal(openAIRequestBody{
+		Model:       t.model,
+		Messages:    openAIMessages(prompt),
+		Temperature: params.Temperature,
+		TopP:        params.TopP,
+		MaxTokens:   params.MaxTokens,
+		Stream:      stream,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to marshal request: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to build request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+	return req, nil
+}
+
+func (t *openAITransport) Generate(ctx context.Context, prompt Prompt, params Params) (string, error) {
+	req, err := t.newRequest(ctx, prompt, params, false)
+	if err != nil {
+		return "", err
+	}
+
+	client := &http.Client{Timeout: openAITimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("[API] Failed to send request: %v", err)
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", err
+	}
+
+	var responseBody openAIResponseBody
+	if err := json.NewDecoder(resp.Body).Decode(&responseBody); err != nil {
+		return "", fmt.Errorf("[API] Failed to decode response: %v", err)
+	}
+	if len(responseBody.Choices) == 0 {
+		return "", fmt.Errorf("[API] No choices in response")
+	}
+
+	return responseBody.Choices[0].Message.Content, nil
+}
+
+func (t *openAITransport) Stream(ctx context.Context, prompt Prompt, params Params) (<-chan Chunk, error) {
+	req, err := t.newRequest(ctx, prompt, params, true)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: openAITimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("[API] Failed to open stream: %v", err)
+	}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	chunks := make(chan Chunk)
+	go func() {
+		defer resp.Body.Close()
+		defer close(chunks)
+
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			data, ok := strings.CutPrefix(line, "data: ")
+			if !ok {
+				continue
+			}
+			if data == "[DONE]" {
+				chunks <- Chunk{Done: true}
+				return
+			}
+
+			var responseBody openAIResponseBody
+			if err := json.Unmarshal([]byte(data), &responseBody); err != nil {
+				return
+			}
+			if len(responseBody.Choices) == 0 {
+				continue
+			}
+			chunks <- Chunk{Text: responseBody.Choices[0].Delta.Content}
+		}
+	}()
+
+	return chunks, nil
+}
+
+// apiKeyFromEnv reads the API key named by cfg.KeyEnv.
+func apiKeyFromEnv(cfg BackendConfig) string {
+	if cfg.KeyEnv == "" {
+		return ""
+	}
+	return os.Getenv(cfg.KeyEnv)
+}
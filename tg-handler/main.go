@@ -8,25 +8,40 @@ import (
 	"os/signal"
 	"syscall"
 	"path/filepath"
-	"sync"
+	"strings"
+	"time"
 
 	tg "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 
 	"tg-handler/api"
 	"tg-handler/memory"
 	"tg-handler/messaging"
+	"tg-handler/ratelimit"
+	"tg-handler/server"
+)
+
+// Coalescing rate for live-editing the streamed reply
+const (
+	EDIT_INTERVAL = 500 * time.Millisecond
+	EDIT_TOKENS   = 20
 )
 
 // Path, structure and loader for Initial Config
 const InitConf = "./confs/init.json"
 
+// SlowDownMsg is sent back when a non-admin is rate limited.
+const SlowDownMsg = "Slow down a bit and try again in a moment."
+
 type InitJSON struct {
-	KeysAPI     []string            `json:"keysAPI"`
-	Admins      []string            `json:"admins"`
-	Orders      map[string][]string `json:"orders"`
-	ConfigPath  string              `json:"config_path"`
-	HistoryPath string              `json:"history_path"`
-	MemoryLimit int                 `json:"memory_limit"`
+	KeysAPI     []string                     `json:"keysAPI"`
+	Admins      []string                     `json:"admins"`
+	Orders      map[string][]string          `json:"orders"`
+	ConfigPath  string                       `json:"config_path"`
+	MemoryLimit int                          `json:"memory_limit"`
+	History     memory.StoreConfig           `json:"history"`
+	Server      server.Config                `json:"server"`
+	Backends    map[string]api.BackendConfig `json:"backends"`
+	RateLimit   ratelimit.Config             `json:"rate_limit"`
 }
 
 func loadInitJSON(config string) *InitJSON {
@@ -47,31 +62,104 @@ func loadInitJSON(config string) *InitJSON {
 	return &initJSON
 }
 
-func handleMessage(c *messaging.ChatInfo, sh *memory.SafeChatHistory) {
-	// Type until reply
+// streamReply sends a placeholder reply on the first chunk, then edits it in
+// coalesced batches as the rest of the stream arrives: on every EDIT_INTERVAL
+// tick, and between ticks once EDIT_TOKENS chunks are pending and
+// EDIT_INTERVAL has passed since the last edit. cancel stops the typing indicator as soon as the
+// placeholder goes out, instead of waiting for the whole response.
+func streamReply(c *messaging.ChatInfo, chunks <-chan api.Chunk, cancel context.CancelFunc) (*tg.Message, string, int) {
+	var text strings.Builder
+	var resp *tg.Message
+	pending := 0
+	tokens := 0
+	lastFlush := time.Now()
+
+	ticker := time.NewTicker(EDIT_INTERVAL)
+	defer ticker.Stop()
+
+	flush := func() {
+		if resp == nil || pending == 0 {
+			return
+		}
+		edit := tg.NewEditMessageText(c.CID, resp.MessageID, text.String())
+		if _, err := c.Bot.Send(edit); err != nil {
+			log.Printf("Failed to edit reply in chat %s: %v", c.ChatTitle, err)
+		}
+		pending = 0
+		lastFlush = time.Now()
+	}
+
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				flush()
+				return resp, text.String(), tokens
+			}
+			text.WriteString(chunk.Text)
+			if chunk.Tokens > 0 {
+				tokens = chunk.Tokens
+			}
+			if resp == nil {
+				cancel()
+				resp = messaging.Reply(c, text.String())
+				continue
+			}
+			pending++
+			if pending >= EDIT_TOKENS && time.Since(lastFlush) >= EDIT_INTERVAL {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func handleMessage(botName string, c *messaging.ChatInfo, store memory.HistoryStore, broadcaster *memory.Broadcaster, backend api.BackendConfig, limiter *ratelimit.Limiter) {
+	// Type until first chunk arrives
 	ctx, cancel := context.WithCancel(context.Background())
 	go messaging.Typing(ctx, c)
 	defer cancel()
 
 	// Add old message pair to history, get it (as interfaces)
 	m := messaging.NewMessageInfo(c.Bot, c.Message.ReplyToMessage)
-	lines := memory.Add([2]memory.IMessage{c, m}, "", sh)
+	lines := memory.Add(botName, c.CID, [2]memory.IMessage{c, m}, "", store, broadcaster)
 
-	// Get dialog, send to API and reply
-	dialog := memory.Get(lines, sh, c.MemoryLimit)
-	text, err := api.Send(dialog, c.Config, c.ChatTitle)
+	// Get dialog, stream from API and reply, editing as chunks arrive
+	dialog := memory.Get(botName, c.CID, lines, store, c.MemoryLimit)
+	chunks, err := api.SendStream(dialog, c.Config, c.ChatTitle, backend)
 	if err != nil {
 		log.Printf("API error in chat %s.", c.ChatTitle)
 		return
 	}
-	resp := messaging.Reply(c, text)
+	resp, text, tokens := streamReply(c, chunks, cancel)
+	if resp == nil || text == "" {
+		log.Printf("API error in chat %s.", c.ChatTitle)
+		return
+	}
+
+	// Charge the daily token budget
+	limiter.Charge(botName, c.CID, tokens)
 
 	// Add new message pair to history (last: interface, previous: reused)
 	m = messaging.NewMessageInfo(c.Bot, resp)
-	memory.Add([2]memory.IMessage{m, nil}, lines[0], sh)
+	memory.Add(botName, c.CID, [2]memory.IMessage{m, nil}, lines[0], store, broadcaster)
 }
 
-func botHandler(i int, initJSON *InitJSON, safeHistory *memory.SafeHistory) {
+// isAdmin reports whether message was sent by one of admins.
+func isAdmin(message *tg.Message, admins []string) bool {
+	if message.From == nil {
+		return false
+	}
+	for _, admin := range admins {
+		if message.From.UserName == admin {
+			return true
+		}
+	}
+	return false
+}
+
+func botHandler(i int, initJSON *InitJSON, store memory.HistoryStore, broadcaster *memory.Broadcaster, limiter *ratelimit.Limiter) {
 	// Start bot from specific keyAPI
 	keysAPI := initJSON.KeysAPI
 	bot, err := tg.NewBotAPI(keysAPI[i])
@@ -86,13 +174,14 @@ func botHandler(i int, initJSON *InitJSON, safeHistory *memory.SafeHistory) {
 	Admins := initJSON.Admins
 	Orders := initJSON.Orders[botName]
 	MemoryLimit := initJSON.MemoryLimit
-	HistoryPath := initJSON.HistoryPath
 	ConfigPath := initJSON.ConfigPath
 
-	// Get bot history and config (order postfix added by OrderInfo)
-	safeBotHistory := safeHistory.Get(botName)
+	// Get bot config (order postfix added by OrderInfo)
 	botConfig := filepath.Join(ConfigPath, botName+"%s.json")
 
+	// Get this bot's LLM backend (zero value resolves to the bespoke server)
+	backend := initJSON.Backends[botName]
+
 	// Start update channel
 	u := tg.NewUpdate(0)
 	u.Timeout = 30
@@ -116,18 +205,26 @@ func botHandler(i int, initJSON *InitJSON, safeHistory *memory.SafeHistory) {
 		}
 		log.Printf("%s got message", botName)
 
-		// Get chat history (ChatInfo: CID, ChatTitle)
+		// Get chat info (ChatInfo: CID, ChatTitle)
 		chatInfo := messaging.NewChatInfo(orderInfo, MemoryLimit)
-		safeChatHistory := safeBotHistory.Get(chatInfo.CID)
+
+		// Rate limit non-admins before doing any LLM work
+		var userID int64
+		if message.From != nil {
+			userID = message.From.ID
+		}
+		if !limiter.Allow(botName, chatInfo.CID, userID, isAdmin(message, Admins)) {
+			messaging.Reply(chatInfo, SlowDownMsg)
+			continue
+		}
 
 		// Handle the message
-		handleMessage(chatInfo, safeChatHistory)
+		handleMessage(botName, chatInfo, store, broadcaster, backend, limiter)
 
-		// Clean and save history
-		memory.CleanHistory(safeHistory)
-		if err := memory.SaveHistory(HistoryPath, safeHistory); err != nil {  
-			log.Printf("Failed to save history for %s: %v", botName, err)  
-		}  
+		// Clean old history entries (store persists each write on its own)
+		if err := store.CleanOlderThan(time.Now().Add(-memory.TIME_LIMIT)); err != nil {
+			log.Printf("Failed to clean history for %s: %v", botName, err)
+		}
 	}
 }
 
@@ -141,21 +238,34 @@ func main() {
 	// Load initialization config
 	initJSON := loadInitJSON(InitConf)
 
-	// Get KeysAPI and HistoryPath
+	// Get KeysAPI
 	KeysAPI := initJSON.KeysAPI
-	HistoryPath := initJSON.HistoryPath
 
-	// Make safe history container
-	history := memory.LoadHistory(HistoryPath)
-	mu := new(sync.RWMutex)
-	safeHistory := memory.NewSafeHistory(history, mu)
+	// Make shared history store (json file store, SQL store, ...)
+	store, err := memory.NewStore(initJSON.History)
+	if err != nil {
+		log.Fatalf("Failed to open history store: %v", err)
+	}
+	defer store.Close()
+
+	// Make shared broadcaster, fed by every bot, consumed by the query API
+	broadcaster := memory.NewBroadcaster()
+
+	// Make shared rate limiter, consulted by every bot before it does any
+	// LLM work
+	limiter := ratelimit.NewLimiter(initJSON.RateLimit)
+
+	// Serve the chat-history query API, if configured
+	if initJSON.Server.Addr != "" {
+		go server.Serve(initJSON.Server, store, broadcaster, limiter)
+	}
 
-	// Start all bots with shared history and mutex
+	// Start all bots against the shared history store
 	for i := range KeysAPI {
-		go botHandler(i, initJSON, safeHistory)
+		go botHandler(i, initJSON, store, broadcaster, limiter)
 	}
 
 	// Block until termination signal
 	<-ctx.Done()
-	log.Println("Shutting down...")  
+	log.Println("Shutting down...")
 }
@@ -0,0 +1,57 @@
+package memory
+
+import "sync"
+
+// Broadcaster fans newly-added history entries out to subscribers, so an
+// HTTP/WebSocket layer can push live updates instead of polling the store.
+type Broadcaster struct {
+	mu   sync.Mutex
+	subs map[string]map[int64][]chan HistoryRecord
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{subs: make(map[string]map[int64][]chan HistoryRecord)}
+}
+
+// Subscribe registers a channel for every future entry added to (bot, chatID).
+// Call the returned function to unregister it.
+func (b *Broadcaster) Subscribe(bot string, chatID int64) (<-chan HistoryRecord, func()) {
+	ch := make(chan HistoryRecord, 16)
+
+	b.mu.Lock()
+	if b.subs[bot] == nil {
+		b.subs[bot] = make(map[int64][]chan HistoryRecord)
+	}
+	b.subs[bot][chatID] = append(b.subs[bot][chatID], ch)
+	b.mu.Unlock()
+
+	unsubscribe := func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		chans := b.subs[bot][chatID]
+		for i, c := range chans {
+			if c == ch {
+				b.subs[bot][chatID] = append(chans[:i], chans[i+1:]...)
+				close(ch)
+				break
+			}
+		}
+	}
+
+	return ch, unsubscribe
+}
+
+// Publish notifies every subscriber of (bot, chatID) about a new entry. It
+// never blocks: a subscriber too slow to keep up simply misses the update.
+func (b *Broadcaster) Publish(bot string, chatID int64, record HistoryRecord) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, ch := range b.subs[bot][chatID] {
+		select {
+		case ch <- record:
+		default:
+		}
+	}
+}
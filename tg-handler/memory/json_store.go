@@ -0,0 +1,342 @@
+package memory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// History types (unchanged on-disk shape from before HistoryStore existed)
+type (
+	jsonHistory     = map[string]jsonBotHistory
+	jsonBotHistory  = map[int64]jsonChatHistory
+	jsonChatHistory = map[string]MessageEntry
+)
+
+// Message type
+type MessageEntry struct {
+	Line      string    `json:"msg"`
+	Timestamp time.Time `json:"ts"`
+	ID        MessageID `json:"id"`
+}
+
+// idIndexEntry is one entry of a chat's MessageID-ordered index.
+type idIndexEntry struct {
+	ID  MessageID
+	Key string
+}
+
+// jsonSaveInterval caps how often the snapshot is rewritten to disk. A
+// processed message touches the store three times (two Adds, one
+// CleanOlderThan); flushing on a timer instead of after every write turns
+// that into at most one rewrite per interval.
+const jsonSaveInterval = 2 * time.Second
+
+// jsonStore is the original in-memory store, snapshotted to a JSON file.
+// Writes just mark the snapshot dirty; a background goroutine rewrites the
+// whole file at most once per jsonSaveInterval, and Close flushes it for
+// good, which is fine for histories that comfortably fit in RAM.
+type jsonStore struct {
+	path string
+	mu   sync.RWMutex
+	data jsonHistory
+
+	seq   map[string]int64          // last assigned MessageID, keyed by indexKey(bot, chatID)
+	order map[string][]idIndexEntry // ascending-by-ID index, keyed by indexKey(bot, chatID)
+
+	dirty bool
+	done  chan struct{}
+}
+
+func newJSONStore(path string) *jsonStore {
+	s := &jsonStore{
+		path:  path,
+		data:  loadJSONFile(path),
+		seq:   make(map[string]int64),
+		order: make(map[string][]idIndexEntry),
+		done:  make(chan struct{}),
+	}
+	s.rebuildIndex()
+	go s.saveLoop()
+	return s
+}
+
+// saveLoop periodically flushes a dirty snapshot to disk until Close stops it.
+func (s *jsonStore) saveLoop() {
+	ticker := time.NewTicker(jsonSaveInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.flushIfDirty()
+		case <-s.done:
+			return
+		}
+	}
+}
+
+func (s *jsonStore) flushIfDirty() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return
+	}
+	if err := s.save(); err != nil {
+		log.Printf("[OS error] Failed to flush history: %v", err)
+		return
+	}
+	s.dirty = false
+}
+
+// rebuildIndex derives the MessageID index and sequence counters from the
+// loaded snapshot. Callers must hold s.mu (or call before publishing s).
+func (s *jsonStore) rebuildIndex() {
+	for bot, botHistory := range s.data {
+		for chatID, chatHistory := range botHistory {
+			chatKey := indexKey(bot, chatID)
+			for key, entry := range chatHistory {
+				s.order[chatKey] = append(s.order[chatKey], idIndexEntry{ID: entry.ID, Key: key})
+				if int64(entry.ID) > s.seq[chatKey] {
+					s.seq[chatKey] = int64(entry.ID)
+				}
+			}
+			sort.Slice(s.order[chatKey], func(i, j int) bool {
+				return s.order[chatKey][i].ID < s.order[chatKey][j].ID
+			})
+		}
+	}
+}
+
+// Load history as shared once (no concurrency)
+func loadJSONFile(source string) jsonHistory {
+	var history jsonHistory
+
+	// Open file (created if needed)
+	file, err := os.OpenFile(source, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		log.Fatalf("[OS error] Failed to open history file: %v", err)
+	}
+	defer file.Close()
+
+	// Read JSON data from file
+	data, err := io.ReadAll(file)
+	if err != nil {
+		log.Fatalf("[OS error] Failed to read history file: %v", err)
+	}
+
+	// Decode JSON data to history
+	if err := json.Unmarshal(data, &history); err != nil {
+		history = make(jsonHistory)
+		log.Println("[OS] History created")
+	} else {
+		log.Println("[OS] History loaded")
+	}
+
+	return history
+}
+
+// chat returns the chat map for (bot, chatID), creating it if absent.
+// Callers must hold s.mu.
+func (s *jsonStore) chat(bot string, chatID int64) jsonChatHistory {
+	botHistory, ok := s.data[bot]
+	if !ok {
+		botHistory = make(jsonBotHistory)
+		s.data[bot] = botHistory
+	}
+	chatHistory, ok := botHistory[chatID]
+	if !ok {
+		chatHistory = make(jsonChatHistory)
+		botHistory[chatID] = chatHistory
+	}
+	return chatHistory
+}
+
+func (s *jsonStore) Add(bot string, chatID int64, messageKey, line string, ts time.Time) (MessageID, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	chatKey := indexKey(bot, chatID)
+	s.seq[chatKey]++
+	id := MessageID(s.seq[chatKey])
+
+	s.chat(bot, chatID)[messageKey] = MessageEntry{Line: line, Timestamp: ts, ID: id}
+	s.order[chatKey] = append(s.order[chatKey], idIndexEntry{ID: id, Key: messageKey})
+	s.dirty = true
+
+	return id, nil
+}
+
+func (s *jsonStore) Get(bot string, chatID int64, messageKey string) (HistoryEntry, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	entry, ok := s.data[bot][chatID][messageKey]
+	if !ok {
+		return HistoryEntry{}, false, nil
+	}
+	return HistoryEntry{Line: entry.Line, Timestamp: entry.Timestamp, ID: entry.ID}, true, nil
+}
+
+func (s *jsonStore) CleanOlderThan(cutoff time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Loop through all chat histories, deleting lines older than cutoff
+	changed := false
+	for bot, botHistory := range s.data {
+		for chatID, chatHistory := range botHistory {
+			var oldLines []string
+			for line, entry := range chatHistory {
+				if entry.Timestamp.Before(cutoff) {
+					oldLines = append(oldLines, line)
+				}
+			}
+			if len(oldLines) == 0 {
+				continue
+			}
+			changed = true
+			for _, line := range oldLines {
+				delete(chatHistory, line)
+			}
+
+			// Drop the same entries from the MessageID index
+			chatKey := indexKey(bot, chatID)
+			order := s.order[chatKey][:0]
+			for _, e := range s.order[chatKey] {
+				if _, ok := chatHistory[e.Key]; ok {
+					order = append(order, e)
+				}
+			}
+			s.order[chatKey] = order
+		}
+	}
+
+	if changed {
+		s.dirty = true
+	}
+	return nil
+}
+
+func (s *jsonStore) Range(bot string, chatID int64, fn func(messageKey string, entry HistoryEntry) bool) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, entry := range s.data[bot][chatID] {
+		if !fn(key, HistoryEntry{Line: entry.Line, Timestamp: entry.Timestamp, ID: entry.ID}) {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *jsonStore) Chats(bot string) ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	chats := make([]int64, 0, len(s.data[bot]))
+	for chatID := range s.data[bot] {
+		chats = append(chats, chatID)
+	}
+	return chats, nil
+}
+
+// SelectHistory answers a CHATHISTORY-style selector against a chat's
+// MessageID-ordered index with a binary search, instead of walking the
+// reply chain one hop at a time.
+func (s *jsonStore) SelectHistory(bot string, chatID int64, selector Selector, target, target2 MessageID, limit int) ([]HistoryRecord, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	order := s.order[indexKey(bot, chatID)]
+	chatData := s.data[bot][chatID]
+
+	toRecord := func(e idIndexEntry) HistoryRecord {
+		entry := chatData[e.Key]
+		return HistoryRecord{MessageKey: e.Key, Line: entry.Line, Timestamp: entry.Timestamp, ID: e.ID}
+	}
+
+	// firstAtLeast returns the index of the first entry with ID >= id.
+	firstAtLeast := func(id MessageID) int {
+		return sort.Search(len(order), func(i int) bool { return order[i].ID >= id })
+	}
+
+	var window []idIndexEntry
+	switch selector {
+	case SelectorLatest:
+		start := max(0, len(order)-limit)
+		window = order[start:]
+
+	case SelectorBefore:
+		end := firstAtLeast(target)
+		start := max(0, end-limit)
+		window = order[start:end]
+
+	case SelectorAfter:
+		start := firstAtLeast(target + 1)
+		end := min(len(order), start+limit)
+		window = order[start:end]
+
+	case SelectorAround:
+		center := firstAtLeast(target)
+		start := max(0, center-limit/2)
+		end := min(len(order), start+limit)
+		window = order[start:end]
+
+	case SelectorBetween:
+		start := firstAtLeast(target)
+		end := firstAtLeast(target2 + 1)
+		if end < start {
+			// target2 < target: an empty range, not a slice to crash on.
+			end = start
+		}
+		if limit > 0 && end-start > limit {
+			end = start + limit
+		}
+		window = order[start:end]
+
+	default:
+		return nil, fmt.Errorf("[OS] Unknown selector: %s", selector)
+	}
+
+	records := make([]HistoryRecord, 0, len(window))
+	for _, e := range window {
+		records = append(records, toRecord(e))
+	}
+	return records, nil
+}
+
+func (s *jsonStore) Close() error {
+	close(s.done)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+// save rewrites the whole snapshot; callers must hold s.mu.
+func (s *jsonStore) save() error {
+	file, err := os.OpenFile(s.path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return fmt.Errorf("[OS error] Failed to open history file: %v", err)
+	}
+	defer file.Close()
+
+	data, err := json.Marshal(s.data)
+	if err != nil {
+		return fmt.Errorf("[OS error] Failed to marshal history: %v", err)
+	}
+
+	if _, err := file.Write(data); err != nil {
+		return fmt.Errorf("[OS error] Failed to write history data: %v", err)
+	}
+
+	log.Println("[OS] History written")
+	return nil
+}
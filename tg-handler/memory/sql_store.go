@@ -0,0 +1,227 @@
+package memory
+
+import (
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+// sqlDriverNames maps our "driver" config value to the registered
+// database/sql driver name. sqlSchema and Add's upsert are SQLite syntax
+// ("?" placeholders, "ON CONFLICT ... DO UPDATE"), so only "sqlite" is
+// listed; see NewStore's doc comment.
+var sqlDriverNames = map[string]string{
+	"sqlite": "sqlite",
+}
+
+const sqlSchema = `
+CREATE TABLE IF NOT EXISTS history (
+	bot         TEXT    NOT NULL,
+	chat_id     INTEGER NOT NULL,
+	message_key TEXT    NOT NULL,
+	prev_line   TEXT    NOT NULL,
+	ts          INTEGER NOT NULL,
+	id          INTEGER NOT NULL,
+	PRIMARY KEY (bot, chat_id, message_key)
+);
+CREATE INDEX IF NOT EXISTS history_chat_ts ON history (bot, chat_id, ts);
+CREATE INDEX IF NOT EXISTS history_chat_id ON history (bot, chat_id, id);
+`
+
+// sqlStore is a SQL-backed HistoryStore. Only the SQLite driver is
+// supported (see sqlDriverNames); MySQL and Postgres are not. Add is a single upsert and CleanOlderThan a single DELETE,
+// so neither rewrites the whole history on every message the way the JSON
+// store does.
+type sqlStore struct {
+	db *sql.DB
+
+	mu sync.Mutex // guards per-chat MessageID assignment in Add
+}
+
+func newSQLStore(driver, dsn string) (*sqlStore, error) {
+	driverName, ok := sqlDriverNames[driver]
+	if !ok {
+		return nil, fmt.Errorf("[OS] Unknown SQL history driver: %s", driver)
+	}
+
+	db, err := sql.Open(driverName, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("[OS error] Failed to open history database: %v", err)
+	}
+
+	if _, err := db.Exec(sqlSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("[OS error] Failed to apply history schema: %v", err)
+	}
+
+	return &sqlStore{db: db}, nil
+}
+
+func (s *sqlStore) Add(bot string, chatID int64, messageKey, line string, ts time.Time) (MessageID, error) {
+	// Guard MessageID assignment: two concurrent bots must not be handed the
+	// same next id for the same chat.
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var maxID sql.NullInt64
+	row := s.db.QueryRow(`SELECT MAX(id) FROM history WHERE bot = ? AND chat_id = ?`, bot, chatID)
+	if err := row.Scan(&maxID); err != nil {
+		return 0, fmt.Errorf("[SQL] Failed to assign message id: %v", err)
+	}
+	id := MessageID(maxID.Int64 + 1)
+
+	_, err := s.db.Exec(`
+		INSERT INTO history (bot, chat_id, message_key, prev_line, ts, id)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (bot, chat_id, message_key)
+		DO UPDATE SET prev_line = excluded.prev_line, ts = excluded.ts, id = excluded.id
+	`, bot, chatID, messageKey, line, ts.Unix(), int64(id))
+	if err != nil {
+		return 0, fmt.Errorf("[SQL] Failed to add history entry: %v", err)
+	}
+	return id, nil
+}
+
+func (s *sqlStore) Get(bot string, chatID int64, messageKey string) (HistoryEntry, bool, error) {
+	row := s.db.QueryRow(`
+		SELECT prev_line, ts, id FROM history
+		WHERE bot = ? AND chat_id = ? AND message_key = ?
+	`, bot, chatID, messageKey)
+
+	var line string
+	var ts, id int64
+	if err := row.Scan(&line, &ts, &id); err != nil {
+		if err == sql.ErrNoRows {
+			return HistoryEntry{}, false, nil
+		}
+		return HistoryEntry{}, false, fmt.Errorf("[SQL] Failed to get history entry: %v", err)
+	}
+
+	return HistoryEntry{Line: line, Timestamp: time.Unix(ts, 0), ID: MessageID(id)}, true, nil
+}
+
+func (s *sqlStore) CleanOlderThan(cutoff time.Time) error {
+	if _, err := s.db.Exec(`DELETE FROM history WHERE ts < ?`, cutoff.Unix()); err != nil {
+		return fmt.Errorf("[SQL] Failed to clean history: %v", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) Range(bot string, chatID int64, fn func(messageKey string, entry HistoryEntry) bool) error {
+	rows, err := s.db.Query(`
+		SELECT message_key, prev_line, ts, id FROM history
+		WHERE bot = ? AND chat_id = ?
+	`, bot, chatID)
+	if err != nil {
+		return fmt.Errorf("[SQL] Failed to range history: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var key, line string
+		var ts, id int64
+		if err := rows.Scan(&key, &line, &ts, &id); err != nil {
+			return fmt.Errorf("[SQL] Failed to scan history entry: %v", err)
+		}
+		if !fn(key, HistoryEntry{Line: line, Timestamp: time.Unix(ts, 0), ID: MessageID(id)}) {
+			break
+		}
+	}
+	return rows.Err()
+}
+
+func (s *sqlStore) Chats(bot string) ([]int64, error) {
+	rows, err := s.db.Query(`SELECT DISTINCT chat_id FROM history WHERE bot = ?`, bot)
+	if err != nil {
+		return nil, fmt.Errorf("[SQL] Failed to list chats: %v", err)
+	}
+	defer rows.Close()
+
+	var chats []int64
+	for rows.Next() {
+		var chatID int64
+		if err := rows.Scan(&chatID); err != nil {
+			return nil, fmt.Errorf("[SQL] Failed to scan chat id: %v", err)
+		}
+		chats = append(chats, chatID)
+	}
+	return chats, rows.Err()
+}
+
+// SelectHistory answers a CHATHISTORY-style selector with a single indexed
+// query against (bot, chat_id, id), instead of walking the reply chain.
+func (s *sqlStore) SelectHistory(bot string, chatID int64, selector Selector, target, target2 MessageID, limit int) ([]HistoryRecord, error) {
+	var rows *sql.Rows
+	var err error
+
+	switch selector {
+	case SelectorLatest:
+		rows, err = s.db.Query(`
+			SELECT message_key, prev_line, ts, id FROM history
+			WHERE bot = ? AND chat_id = ?
+			ORDER BY id DESC LIMIT ?
+		`, bot, chatID, limit)
+
+	case SelectorBefore:
+		rows, err = s.db.Query(`
+			SELECT message_key, prev_line, ts, id FROM history
+			WHERE bot = ? AND chat_id = ? AND id < ?
+			ORDER BY id DESC LIMIT ?
+		`, bot, chatID, int64(target), limit)
+
+	case SelectorAfter:
+		rows, err = s.db.Query(`
+			SELECT message_key, prev_line, ts, id FROM history
+			WHERE bot = ? AND chat_id = ? AND id > ?
+			ORDER BY id ASC LIMIT ?
+		`, bot, chatID, int64(target), limit)
+
+	case SelectorAround:
+		rows, err = s.db.Query(`
+			SELECT message_key, prev_line, ts, id FROM history
+			WHERE bot = ? AND chat_id = ? AND id >= ?
+			ORDER BY id ASC LIMIT ?
+		`, bot, chatID, int64(target)-int64(limit/2), limit)
+
+	case SelectorBetween:
+		rows, err = s.db.Query(`
+			SELECT message_key, prev_line, ts, id FROM history
+			WHERE bot = ? AND chat_id = ? AND id BETWEEN ? AND ?
+			ORDER BY id ASC LIMIT ?
+		`, bot, chatID, int64(target), int64(target2), limit)
+
+	default:
+		return nil, fmt.Errorf("[OS] Unknown selector: %s", selector)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("[SQL] Failed to select history: %v", err)
+	}
+	defer rows.Close()
+
+	var records []HistoryRecord
+	for rows.Next() {
+		var key, line string
+		var ts, id int64
+		if err := rows.Scan(&key, &line, &ts, &id); err != nil {
+			return nil, fmt.Errorf("[SQL] Failed to scan history entry: %v", err)
+		}
+		records = append(records, HistoryRecord{MessageKey: key, Line: line, Timestamp: time.Unix(ts, 0), ID: MessageID(id)})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// BEFORE and LATEST are queried newest-first to make LIMIT cut the right
+	// end; re-chronologize before returning.
+	if selector == SelectorBefore || selector == SelectorLatest {
+		reverseRecords(records)
+	}
+	return records, nil
+}
+
+func (s *sqlStore) Close() error {
+	return s.db.Close()
+}
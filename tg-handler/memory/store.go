@@ -0,0 +1,113 @@
+package memory
+
+import (
+	"fmt"
+	"time"
+)
+
+// Time limit for line deletion from history
+const TIME_LIMIT = 24 * time.Hour
+
+// MessageID is the monotonic, per-chat sequence number HistoryStore.Add
+// assigns to every entry, used to answer CHATHISTORY-style selectors without
+// scanning the whole reply chain.
+type MessageID int64
+
+// Selector is an IRCv3 draft/chathistory-style history selector.
+type Selector string
+
+const (
+	SelectorLatest  Selector = "LATEST"  // newest limit entries
+	SelectorBefore  Selector = "BEFORE"  // up to limit entries strictly older than target
+	SelectorAfter   Selector = "AFTER"   // up to limit entries strictly newer than target
+	SelectorAround  Selector = "AROUND"  // roughly limit/2 entries on each side of target
+	SelectorBetween Selector = "BETWEEN" // entries between target and target2, inclusive
+)
+
+// HistoryEntry is one stored reply-chain link: messageKey replied to Line.
+type HistoryEntry struct {
+	Line      string
+	Timestamp time.Time
+	ID        MessageID
+}
+
+// HistoryRecord is a HistoryEntry together with the message key it belongs to.
+type HistoryRecord struct {
+	MessageKey string    `json:"message_key"`
+	Line       string    `json:"line"`
+	Timestamp  time.Time `json:"ts"`
+	ID         MessageID `json:"id"`
+}
+
+// HistoryStore persists reply-chain links keyed by (bot, chat, message key),
+// so the JSON file store and the SQL-backed store can be swapped in without
+// touching the reply-chain logic in Add/Get.
+type HistoryStore interface {
+	// Add records that messageKey replied to line at ts, returning the
+	// monotonic MessageID assigned to it within (bot, chatID).
+	Add(bot string, chatID int64, messageKey, line string, ts time.Time) (MessageID, error)
+	// Get returns the line messageKey replied to, if any.
+	Get(bot string, chatID int64, messageKey string) (HistoryEntry, bool, error)
+	// CleanOlderThan deletes every entry older than cutoff, across all bots and chats.
+	CleanOlderThan(cutoff time.Time) error
+	// Range calls fn for every entry of one chat, stopping early if fn returns false.
+	Range(bot string, chatID int64, fn func(messageKey string, entry HistoryEntry) bool) error
+	// Chats lists every chat ID with history recorded for bot.
+	Chats(bot string) ([]int64, error)
+	// SelectHistory answers a CHATHISTORY-style selector against one chat's
+	// history, ordered chronologically. target2 is only used by SelectorBetween.
+	SelectHistory(bot string, chatID int64, selector Selector, target, target2 MessageID, limit int) ([]HistoryRecord, error)
+	Close() error
+}
+
+// StoreConfig selects and configures a HistoryStore (init.json "history").
+type StoreConfig struct {
+	Driver string `json:"driver"` // "json" (default), "sqlite"
+	DSN    string `json:"dsn"`    // SQL data source name, unused by the json driver
+	Path   string `json:"path"`   // JSON snapshot path, used by the json driver
+}
+
+// NewStore builds the HistoryStore selected by cfg, falling back to the JSON
+// file store when no driver is given.
+//
+// Only "sqlite" is wired up for the SQL-backed store: sqlSchema and Add's
+// upsert use "?" placeholders, which is SQLite syntax. MySQL and Postgres
+// would need their own schema/DML (placeholder style, ON CONFLICT vs ON
+// DUPLICATE KEY UPDATE, ...), not just a driver import, so they aren't
+// offered as a Driver value until that's done.
+func NewStore(cfg StoreConfig) (HistoryStore, error) {
+	switch cfg.Driver {
+	case "", "json":
+		return newJSONStore(cfg.Path), nil
+	case "sqlite":
+		return newSQLStore(cfg.Driver, cfg.DSN)
+	default:
+		return nil, fmt.Errorf("[OS] Unknown history driver: %s", cfg.Driver)
+	}
+}
+
+// Query answers an IRCv3 draft/chathistory-style request against one chat's
+// history, keyed by the monotonic MessageID HistoryStore.Add assigns to each
+// entry (instead of walking the reply chain one hop at a time). target2 is
+// only meaningful for SelectorBetween.
+func Query(bot string, chatID int64, selector Selector, target, target2 MessageID, limit int, store HistoryStore) ([]HistoryRecord, error) {
+	switch selector {
+	case SelectorLatest, SelectorBefore, SelectorAfter, SelectorAround, SelectorBetween:
+		return store.SelectHistory(bot, chatID, selector, target, target2, limit)
+	default:
+		return nil, fmt.Errorf("[OS] Unknown selector: %s", selector)
+	}
+}
+
+// reverseRecords reverses records in place.
+func reverseRecords(records []HistoryRecord) {
+	for i, j := 0, len(records)-1; i < j; i, j = i+1, j-1 {
+		records[i], records[j] = records[j], records[i]
+	}
+}
+
+// indexKey scopes a per-chat index (the jsonStore's order/seq maps) under
+// one string key.
+func indexKey(bot string, chatID int64) string {
+	return fmt.Sprintf("%s:%d", bot, chatID)
+}
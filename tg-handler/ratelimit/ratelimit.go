@@ -0,0 +1,205 @@
+// Package ratelimit guards the bots against abusive request rates and
+// runaway token spend, on top of (not instead of) the existing Admins
+// bypass.
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Config holds the rates loaded from init.json's "rate_limit" block. Any
+// rate left at zero is treated as unlimited.
+type Config struct {
+	ChatRPM         float64 `json:"chat_rpm"`
+	UserRPM         float64 `json:"user_rpm"`
+	ChatDailyTokens float64 `json:"chat_daily_tokens"`
+}
+
+// bucket is a classic token bucket, refilled at a constant rate per minute
+// and capped at that same rate (so a chat/user can never save up more than
+// one minute's worth of burst).
+type bucket struct {
+	tokens  float64
+	rate    float64 // tokens per second
+	updated time.Time
+}
+
+func newBucket(ratePerMinute float64) *bucket {
+	return &bucket{tokens: ratePerMinute, rate: ratePerMinute / 60}
+}
+
+// refill tops the bucket up for the elapsed time and reports whether a
+// token is available, without spending it (see consume).
+func (b *bucket) refill(now time.Time) bool {
+	elapsed := now.Sub(b.updated).Seconds()
+	b.tokens = min(b.rate*60, b.tokens+elapsed*b.rate)
+	b.updated = now
+	return b.tokens >= 1
+}
+
+func (b *bucket) consume() {
+	b.tokens--
+}
+
+// dayBudget is a token budget that resets the first time it's touched on a
+// new calendar day.
+type dayBudget struct {
+	remaining float64
+	day       string
+}
+
+func (d *dayBudget) refresh(now time.Time, capacity float64) {
+	day := now.Format("2006-01-02")
+	if d.day != day {
+		d.day = day
+		d.remaining = capacity
+	}
+}
+
+// ChatStatus is a chat's current daily token budget, exposed over the
+// history HTTP API's /v1/status route. ChatTokensLeft is -1 when
+// ChatDailyTokens is unconfigured (no budget enforced).
+type ChatStatus struct {
+	ChatTokensLeft float64 `json:"chat_tokens_left"`
+}
+
+// Limiter enforces per-chat and per-user request rates, plus a per-chat
+// daily token budget, all keyed by bot name alongside the chat/user id.
+type Limiter struct {
+	cfg Config
+
+	mu     sync.Mutex
+	chats  map[string]*bucket
+	users  map[string]*bucket
+	budget map[string]*dayBudget
+}
+
+// NewLimiter builds a Limiter from cfg. A zero Config disables every check.
+func NewLimiter(cfg Config) *Limiter {
+	return &Limiter{
+		cfg:    cfg,
+		chats:  make(map[string]*bucket),
+		users:  make(map[string]*bucket),
+		budget: make(map[string]*dayBudget),
+	}
+}
+
+func bucketKey(bot string, id int64) string {
+	return fmt.Sprintf("%s:%d", bot, id)
+}
+
+// userBucketKey scopes the per-user bucket to the chat whenever userID is
+// unknown (e.g. anonymous group-admin posts), so that every anonymous
+// sender across every chat doesn't collapse onto one shared bucket.
+func userBucketKey(bot string, chatID, userID int64) string {
+	if userID == 0 {
+		return fmt.Sprintf("%s:chat:%d", bot, chatID)
+	}
+	return bucketKey(bot, userID)
+}
+
+// Allow reports whether a new request from (bot, chatID, userID) may
+// proceed. Admins always may; everyone else is rejected once the chat's
+// daily token budget is spent, or either rate bucket runs dry. Buckets are
+// only spent once every check has passed, so one user tripping the user
+// bucket never drains tokens out of the shared chat bucket.
+func (l *Limiter) Allow(bot string, chatID, userID int64, isAdmin bool) bool {
+	if isAdmin {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	if l.cfg.ChatDailyTokens > 0 {
+		key := bucketKey(bot, chatID)
+		b, ok := l.budget[key]
+		if !ok {
+			b = &dayBudget{}
+			l.budget[key] = b
+		}
+		b.refresh(now, l.cfg.ChatDailyTokens)
+		if b.remaining <= 0 {
+			return false
+		}
+	}
+
+	var chatBucket, userBucket *bucket
+
+	if l.cfg.ChatRPM > 0 {
+		key := bucketKey(bot, chatID)
+		b, ok := l.chats[key]
+		if !ok {
+			b = newBucket(l.cfg.ChatRPM)
+			l.chats[key] = b
+		}
+		if !b.refill(now) {
+			return false
+		}
+		chatBucket = b
+	}
+
+	if l.cfg.UserRPM > 0 {
+		key := userBucketKey(bot, chatID, userID)
+		b, ok := l.users[key]
+		if !ok {
+			b = newBucket(l.cfg.UserRPM)
+			l.users[key] = b
+		}
+		if !b.refill(now) {
+			return false
+		}
+		userBucket = b
+	}
+
+	// Every check passed: now actually spend the tokens.
+	if chatBucket != nil {
+		chatBucket.consume()
+	}
+	if userBucket != nil {
+		userBucket.consume()
+	}
+	return true
+}
+
+// Charge deducts tokens from (bot, chatID)'s daily token budget, once the
+// reply has actually been sent.
+func (l *Limiter) Charge(bot string, chatID int64, tokens int) {
+	if l.cfg.ChatDailyTokens <= 0 || tokens <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := bucketKey(bot, chatID)
+	b, ok := l.budget[key]
+	if !ok {
+		b = &dayBudget{}
+		l.budget[key] = b
+	}
+	b.refresh(time.Now(), l.cfg.ChatDailyTokens)
+	b.remaining -= float64(tokens)
+}
+
+// Status returns (bot, chatID)'s current daily token budget.
+func (l *Limiter) Status(bot string, chatID int64) ChatStatus {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cfg.ChatDailyTokens <= 0 {
+		return ChatStatus{ChatTokensLeft: -1}
+	}
+
+	key := bucketKey(bot, chatID)
+	b, ok := l.budget[key]
+	if !ok {
+		return ChatStatus{ChatTokensLeft: l.cfg.ChatDailyTokens}
+	}
+	b.refresh(time.Now(), l.cfg.ChatDailyTokens)
+	return ChatStatus{ChatTokensLeft: b.remaining}
+}
@@ -0,0 +1,79 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"tg-handler/memory"
+)
+
+const defaultHistoryLimit = 50
+
+// handleHistory serves GET /v1/history?bot=&chat_id=&selector=&id=&id2=&limit=,
+// answering IRCv3 draft/chathistory-style selectors (LATEST, BEFORE, AFTER,
+// AROUND, BETWEEN) by MessageID instead of timestamp.
+func handleHistory(store memory.HistoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		bot := query.Get("bot")
+		chatID, err := strconv.ParseInt(query.Get("chat_id"), 10, 64)
+		if bot == "" || err != nil {
+			http.Error(w, "bot and chat_id are required", http.StatusBadRequest)
+			return
+		}
+
+		selector := memory.Selector(query.Get("selector"))
+		if selector == "" {
+			selector = memory.SelectorLatest
+		}
+
+		limit := defaultHistoryLimit
+		if l, err := strconv.Atoi(query.Get("limit")); err == nil && l > 0 {
+			limit = l
+		}
+
+		target := parseMessageID(query.Get("id"))
+		target2 := parseMessageID(query.Get("id2"))
+
+		if selector == memory.SelectorBetween && target > target2 {
+			http.Error(w, "id must be <= id2 for a BETWEEN selector", http.StatusBadRequest)
+			return
+		}
+
+		records, err := memory.Query(bot, chatID, selector, target, target2, limit, store)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(records)
+	}
+}
+
+// handleChats serves GET /v1/chats?bot=, listing every known chat ID.
+func handleChats(store memory.HistoryStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bot := r.URL.Query().Get("bot")
+		if bot == "" {
+			http.Error(w, "bot is required", http.StatusBadRequest)
+			return
+		}
+
+		chats, err := store.Chats(bot)
+		if err != nil {
+			http.Error(w, "failed to list chats", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(chats)
+	}
+}
+
+func parseMessageID(s string) memory.MessageID {
+	id, _ := strconv.ParseInt(s, 10, 64)
+	return memory.MessageID(id)
+}
@@ -0,0 +1,45 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/websocket"
+
+	"tg-handler/memory"
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// handleListen serves GET /v1/listen?bot=&chat_id=, upgrading to a WebSocket
+// that pushes every new pair memory.Add records for that chat.
+func handleListen(broadcaster *memory.Broadcaster) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bot := r.URL.Query().Get("bot")
+		chatID, err := strconv.ParseInt(r.URL.Query().Get("chat_id"), 10, 64)
+		if bot == "" || err != nil {
+			http.Error(w, "bot and chat_id are required", http.StatusBadRequest)
+			return
+		}
+
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("[Server] Failed to upgrade to websocket: %v", err)
+			return
+		}
+		defer conn.Close()
+
+		records, unsubscribe := broadcaster.Subscribe(bot, chatID)
+		defer unsubscribe()
+
+		for record := range records {
+			if err := conn.WriteJSON(record); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package server exposes the bots' persisted chat histories over an
+// authenticated HTTP/WebSocket API, so dashboards can query transcripts
+// without touching the history store directly.
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"tg-handler/memory"
+	"tg-handler/ratelimit"
+)
+
+// Config selects whether and where the chat-history query API listens
+// (init.json "server").
+type Config struct {
+	Addr string `json:"addr"` // e.g. ":8090"; empty disables the server
+
+	// Tokens are opaque bearer secrets for this API, generated and
+	// distributed out of band. They are NOT the "admins" list of public
+	// Telegram @usernames used elsewhere (those are guessable by anyone
+	// who has ever seen an admin post, so they're worthless as a secret).
+	Tokens []string `json:"tokens"`
+}
+
+// Serve starts the chat-history HTTP/WebSocket API and blocks until it exits.
+// Every route requires a bearer token from cfg.Tokens.
+func Serve(cfg Config, store memory.HistoryStore, broadcaster *memory.Broadcaster, limiter *ratelimit.Limiter) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/history", authorize(cfg.Tokens, handleHistory(store)))
+	mux.HandleFunc("/v1/listen", authorize(cfg.Tokens, handleListen(broadcaster)))
+	mux.HandleFunc("/v1/chats", authorize(cfg.Tokens, handleChats(store)))
+	mux.HandleFunc("/v1/status", authorize(cfg.Tokens, handleStatus(limiter)))
+
+	log.Printf("[Server] Listening on %s", cfg.Addr)
+	if err := http.ListenAndServe(cfg.Addr, mux); err != nil {
+		log.Printf("[Server] Stopped: %v", err)
+	}
+}
+
+// authorize requires a bearer token matching one of tokens.
+func authorize(tokens []string, next http.HandlerFunc) http.HandlerFunc {
+	allowed := make(map[string]bool, len(tokens))
+	for _, token := range tokens {
+		allowed[token] = true
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || !allowed[token] {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"tg-handler/ratelimit"
+)
+
+// handleStatus serves GET /v1/status?bot=&chat_id=, reporting the chat's
+// current rate-limit bucket state (e.g. remaining daily token budget).
+func handleStatus(limiter *ratelimit.Limiter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+
+		bot := query.Get("bot")
+		chatID, err := strconv.ParseInt(query.Get("chat_id"), 10, 64)
+		if bot == "" || err != nil {
+			http.Error(w, "bot and chat_id are required", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(limiter.Status(bot, chatID))
+	}
+}